@@ -0,0 +1,60 @@
+package switchdevfs_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Mellanox/sriovnet"
+	"github.com/Mellanox/sriovnet/pkg/utils/filesystem"
+
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/testing/switchdevfs"
+)
+
+func ExampleBuild() {
+	tmpDir, err := os.MkdirTemp("", "switchdevfs")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	fs, teardown, err := filesystem.NewFakeFs(filepath.Join(tmpDir, "fakefs"))
+	if err != nil {
+		panic(err)
+	}
+	defer teardown()
+	origFs := filesystem.Fs
+	filesystem.Fs = fs
+	defer func() { filesystem.Fs = origFs }()
+
+	spec := switchdevfs.Spec{
+		Uplinks: []switchdevfs.Uplink{{
+			Name:           "p0",
+			PciAddress:     "0000:03:00.0",
+			SwitchID:       "c2cfc60003a1420c",
+			VfPciAddresses: []string{"0000:03:00.2", "0000:03:00.3"},
+			Representors: []switchdevfs.Representor{
+				{Name: "pf0hpf", PhysPortName: "pf0"},
+				{Name: "pf0vf0", PhysPortName: "pf0vf0"},
+				{Name: "pf0vf1", PhysPortName: "pf0vf1"},
+			},
+		}},
+		Netdevs: []string{"eth0"},
+	}
+	if err := switchdevfs.Build(fs, sriovnet.NetSysDir, sriovnet.PciSysDir, spec); err != nil {
+		panic(err)
+	}
+
+	uplink, err := sriovnet.GetUplinkRepresentor("0000:03:00.3")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(uplink)
+	rep, err := sriovnet.GetVfRepresentor(uplink, 1)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(rep)
+	// Output:
+	// p0
+	// pf0vf1
+}
@@ -0,0 +1,184 @@
+// Package switchdevfs builds fake switchdev sysfs trees for sriovnet based unit tests.
+//
+// A tree is described declaratively by a Spec and written to a filesystem.Filesystem,
+// typically the sriovnet fake filesystem returned by filesystem.NewFakeFs, e.g:
+//
+//	fs, teardown, err := filesystem.NewFakeFs("/tmp/sriovnet-fakefs")
+//	if err != nil {
+//		...
+//	}
+//	defer teardown()
+//	filesystem.Fs = fs
+//	spec := switchdevfs.Spec{Uplinks: []switchdevfs.Uplink{{
+//		Name:           "p0",
+//		PciAddress:     "0000:03:00.0",
+//		SwitchID:       "c2cfc60003a1420c",
+//		VfPciAddresses: []string{"0000:03:00.2"},
+//		Representors: []switchdevfs.Representor{
+//			{Name: "pf0hpf", PhysPortName: "pf0"},
+//			{Name: "pf0vf0", PhysPortName: "pf0vf0"},
+//		},
+//	}}}
+//	if err := switchdevfs.Build(fs, sriovnet.NetSysDir, sriovnet.PciSysDir, spec); err != nil {
+//		...
+//	}
+//	uplink, err := sriovnet.GetUplinkRepresentor("0000:03:00.2") // "p0"
+package switchdevfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Mellanox/sriovnet/pkg/utils/filesystem"
+)
+
+// Representor describes a representor netdev on the eswitch of an uplink
+type Representor struct {
+	// Name is the representor netdev name e.g pf0vf0
+	Name string
+	// PhysPortName is the representor phys_port_name e.g pf0vf0, c1pf0vf0 or pf0sf1
+	PhysPortName string
+	// PfDevice links the representor device to the uplink PCI device, as done by drivers
+	// registering representors on the PF e.g with the old kernel phys_port_name syntax <vf_num>
+	PfDevice bool
+}
+
+// Uplink describes an uplink netdev, the PCI device it belongs to and the representors on its eswitch
+type Uplink struct {
+	// Name is the uplink netdev name e.g p0
+	Name string
+	// PciAddress is the PCI address of the PF of the uplink e.g 0000:03:00.0
+	PciAddress string
+	// PhysPortName is the uplink phys_port_name, defaults to Name if empty
+	PhysPortName string
+	// SwitchID is the phys_switch_id shared by the uplink and its representors
+	SwitchID string
+	// VfPciAddresses are the PCI addresses of the PF VFs, ordered by VF index
+	VfPciAddresses []string
+	// TotalVfs is the PF sriov_totalvfs, if lower than the number of VFs that number is used
+	TotalVfs int
+	// SymlinkNetEntry creates the PF net/<Name> entry as a symlink to the netdev directory rather
+	// than a directory, as seen on some kernels or when /sys is bind mounted in a container
+	SymlinkNetEntry bool
+	// Representors are the representors on the uplink eswitch
+	Representors []Representor
+}
+
+// Spec describes a switchdev sysfs tree
+type Spec struct {
+	Uplinks []Uplink
+	// Netdevs are non switchdev netdevs to create e.g eth0
+	Netdevs []string
+}
+
+// Build writes the sysfs tree described by spec into fs, under the given net class and PCI devices roots.
+func Build(fs filesystem.Filesystem, netSysDir, pciSysDir string, spec Spec) error {
+	for _, netdev := range spec.Netdevs {
+		if err := buildNetdev(fs, netSysDir, netdev, "", ""); err != nil {
+			return err
+		}
+	}
+	for _, uplink := range spec.Uplinks {
+		if err := buildUplink(fs, netSysDir, pciSysDir, &uplink); err != nil {
+			return fmt.Errorf("failed to build uplink %s. %v", uplink.Name, err)
+		}
+	}
+	return nil
+}
+
+func buildUplink(fs filesystem.Filesystem, netSysDir, pciSysDir string, uplink *Uplink) error {
+	physPortName := uplink.PhysPortName
+	if physPortName == "" {
+		physPortName = uplink.Name
+	}
+	if err := buildNetdev(fs, netSysDir, uplink.Name, uplink.SwitchID, physPortName); err != nil {
+		return err
+	}
+	uplinkDir := filepath.Join(netSysDir, uplink.Name)
+
+	if uplink.PciAddress != "" {
+		pfDir := filepath.Join(pciSysDir, uplink.PciAddress)
+		if err := buildNetEntry(fs, pfDir, uplinkDir, uplink.Name, uplink.SymlinkNetEntry); err != nil {
+			return err
+		}
+		if err := fs.Symlink(pfDir, filepath.Join(uplinkDir, "device")); err != nil {
+			return err
+		}
+		for vfIndex, vfPciAddress := range uplink.VfPciAddresses {
+			vfDir := filepath.Join(pciSysDir, vfPciAddress)
+			if err := fs.MkdirAll(vfDir, os.FileMode(0755)); err != nil {
+				return err
+			}
+			if err := fs.Symlink(pfDir, filepath.Join(vfDir, "physfn")); err != nil {
+				return err
+			}
+			if err := fs.Symlink(vfDir, filepath.Join(pfDir, fmt.Sprintf("virtfn%d", vfIndex))); err != nil {
+				return err
+			}
+		}
+		if err := buildSriovFiles(fs, pfDir, len(uplink.VfPciAddresses), uplink.TotalVfs); err != nil {
+			return err
+		}
+	}
+
+	for _, rep := range uplink.Representors {
+		if err := buildNetdev(fs, netSysDir, rep.Name, uplink.SwitchID, rep.PhysPortName); err != nil {
+			return err
+		}
+		if rep.PfDevice && uplink.PciAddress != "" {
+			pfDir := filepath.Join(pciSysDir, uplink.PciAddress)
+			if err := fs.Symlink(pfDir, filepath.Join(netSysDir, rep.Name, "device")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func buildNetdev(fs filesystem.Filesystem, netSysDir, name, switchID, physPortName string) error {
+	netdevDir := filepath.Join(netSysDir, name)
+	if err := fs.MkdirAll(netdevDir, os.FileMode(0755)); err != nil {
+		return err
+	}
+	// subsystem links back to the net class directory, listing all netdevs
+	if err := fs.Symlink(netSysDir, filepath.Join(netdevDir, "subsystem")); err != nil {
+		return err
+	}
+	if switchID == "" {
+		return nil
+	}
+	if err := fs.WriteFile(filepath.Join(netdevDir, "phys_switch_id"), []byte(switchID), os.FileMode(0644)); err != nil {
+		return err
+	}
+	if physPortName == "" {
+		return nil
+	}
+	return fs.WriteFile(filepath.Join(netdevDir, "phys_port_name"), []byte(physPortName), os.FileMode(0644))
+}
+
+func buildNetEntry(fs filesystem.Filesystem, pfDir, netdevDir, name string, symlink bool) error {
+	netEntry := filepath.Join(pfDir, "net", name)
+	if !symlink {
+		return fs.MkdirAll(netEntry, os.FileMode(0755))
+	}
+	if err := fs.MkdirAll(filepath.Dir(netEntry), os.FileMode(0755)); err != nil {
+		return err
+	}
+	return fs.Symlink(netdevDir, netEntry)
+}
+
+func buildSriovFiles(fs filesystem.Filesystem, pfDir string, numVfs, totalVfs int) error {
+	if numVfs == 0 && totalVfs == 0 {
+		return nil
+	}
+	if totalVfs < numVfs {
+		totalVfs = numVfs
+	}
+	err := fs.WriteFile(filepath.Join(pfDir, "sriov_numvfs"), []byte(strconv.Itoa(numVfs)), os.FileMode(0644))
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(filepath.Join(pfDir, "sriov_totalvfs"), []byte(strconv.Itoa(totalVfs)), os.FileMode(0644))
+}
@@ -0,0 +1,249 @@
+//go:build linux
+// +build linux
+
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+
+	"github.com/Mellanox/sriovnet"
+	"gopkg.in/fsnotify/fsnotify.v1"
+)
+
+// repCacheKey identifies a cached representor lookup
+type repCacheKey struct {
+	// lookup is the name of the lookup function e.g GetVfRepresentor
+	lookup string
+	// dev is the uplink netdev name or the PCI address the lookup was made with
+	dev   string
+	index int
+}
+
+// RepresentorCache caches representor lookups of a sriovnet.SwitchdevProvider.
+// Entries are invalidated when a netdev they refer to is created or removed under NetSysDir, as reported by
+// an fsnotify watcher, or explicitly through Invalidate and InvalidateNetdevs. If the watcher is not enabled
+// callers are responsible for invalidating entries. If the watcher was requested but failed to initialize or
+// stopped, lookups are served directly from sysfs.
+// Note: sysfs does not emit inotify events for netdevs created or removed by the kernel on all kernel
+// versions, callers running on such kernels should invalidate the cache on netlink link events instead.
+// Cached peer MAC addresses are not invalidated on MAC address changes, callers changing them should invalidate
+// the representor entries through InvalidateNetdevs.
+// RepresentorCache is safe for concurrent use.
+type RepresentorCache struct {
+	provider *sriovnet.SwitchdevProvider
+
+	mu sync.RWMutex
+	// bypass is set when lookups should not be cached
+	bypass  bool
+	entries map[repCacheKey]string
+	// gen is incremented on every invalidation, a lookup result is only cached if no invalidation
+	// happened while it was looked up
+	gen uint64
+
+	watcher   *fsnotify.Watcher
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewRepresentorCache returns a RepresentorCache for the given provider, the sriovnet default provider is used
+// if nil.
+// If watch is set, netdevs under the provider NetSysDir are watched to invalidate cached entries, in case
+// the watcher fails to initialize lookups are served directly from sysfs.
+// Note: the watcher operates on the host filesystem, regardless of the provider Fs.
+func NewRepresentorCache(p *sriovnet.SwitchdevProvider, watch bool) *RepresentorCache {
+	if p == nil {
+		p = sriovnet.DefaultSwitchdevProvider()
+	}
+	c := &RepresentorCache{
+		provider: p,
+		entries:  make(map[repCacheKey]string),
+		done:     make(chan struct{}),
+	}
+	if !watch {
+		return c
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.bypass = true
+		return c
+	}
+	if err := watcher.Add(p.NetSysDir); err != nil {
+		_ = watcher.Close()
+		c.bypass = true
+		return c
+	}
+	c.watcher = watcher
+	c.wg.Add(1)
+	go c.watch()
+	return c
+}
+
+// watch invalidates cache entries on NetSysDir events until the cache is closed or the watcher fails
+func (c *RepresentorCache) watch() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.done:
+			return
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				c.stopCaching()
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+				c.InvalidateNetdevs(filepath.Base(event.Name))
+			}
+		case _, ok := <-c.watcher.Errors:
+			// events may have been lost e.g on inotify queue overflow
+			if !ok {
+				c.stopCaching()
+				return
+			}
+			c.Invalidate()
+		}
+	}
+}
+
+// stopCaching drops all entries and serves further lookups directly from sysfs
+func (c *RepresentorCache) stopCaching() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bypass = true
+	c.entries = make(map[repCacheKey]string)
+	c.gen++
+}
+
+// Close stops the cache watcher, if any. Lookups made after Close are served directly from sysfs.
+func (c *RepresentorCache) Close() error {
+	if c.watcher == nil {
+		return nil
+	}
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.watcher.Close()
+		c.wg.Wait()
+		c.stopCaching()
+	})
+	return err
+}
+
+// Invalidate drops all cached entries
+func (c *RepresentorCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[repCacheKey]string)
+	c.gen++
+}
+
+// InvalidateNetdevs drops the cached entries which resolved to or were looked up by one of the given netdevs
+func (c *RepresentorCache) InvalidateNetdevs(netdevs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, netdev := range netdevs {
+		for key, rep := range c.entries {
+			// uplink lookups are keyed by PCI address, a created netdev may be a new uplink of that device
+			if rep == netdev || key.dev == netdev || key.lookup == "GetUplinkRepresentor" {
+				delete(c.entries, key)
+			}
+		}
+	}
+}
+
+// lookup returns the cached result of key, calling fn and caching its result on a miss.
+// Failed lookups are not cached.
+func (c *RepresentorCache) lookup(key repCacheKey, fn func() (string, error)) (string, error) {
+	c.mu.RLock()
+	rep, ok := c.entries[key]
+	bypass := c.bypass
+	gen := c.gen
+	c.mu.RUnlock()
+	if ok {
+		return rep, nil
+	}
+
+	rep, err := fn()
+	if err != nil || bypass {
+		return rep, err
+	}
+	c.mu.Lock()
+	if !c.bypass && c.gen == gen {
+		c.entries[key] = rep
+	}
+	c.mu.Unlock()
+	return rep, nil
+}
+
+// GetUplinkRepresentor is sriovnet.SwitchdevProvider.GetUplinkRepresentor served from the cache
+func (c *RepresentorCache) GetUplinkRepresentor(pciAddress string) (string, error) {
+	return c.lookup(repCacheKey{lookup: "GetUplinkRepresentor", dev: pciAddress}, func() (string, error) {
+		return c.provider.GetUplinkRepresentor(pciAddress)
+	})
+}
+
+// GetVfRepresentor is sriovnet.SwitchdevProvider.GetVfRepresentor served from the cache
+func (c *RepresentorCache) GetVfRepresentor(uplink string, vfIndex int) (string, error) {
+	return c.lookup(repCacheKey{lookup: "GetVfRepresentor", dev: uplink, index: vfIndex}, func() (string, error) {
+		return c.provider.GetVfRepresentor(uplink, vfIndex)
+	})
+}
+
+// GetPfRepresentor is sriovnet.SwitchdevProvider.GetPfRepresentor served from the cache
+func (c *RepresentorCache) GetPfRepresentor(uplink string, pfIndex int) (string, error) {
+	return c.lookup(repCacheKey{lookup: "GetPfRepresentor", dev: uplink, index: pfIndex}, func() (string, error) {
+		return c.provider.GetPfRepresentor(uplink, pfIndex)
+	})
+}
+
+// GetSfRepresentor is sriovnet.SwitchdevProvider.GetSfRepresentor served from the cache
+func (c *RepresentorCache) GetSfRepresentor(uplink string, sfIndex int) (string, error) {
+	return c.lookup(repCacheKey{lookup: "GetSfRepresentor", dev: uplink, index: sfIndex}, func() (string, error) {
+		return c.provider.GetSfRepresentor(uplink, sfIndex)
+	})
+}
+
+// GetVfRepresentorDPU is sriovnet.SwitchdevProvider.GetVfRepresentorDPU served from the cache
+func (c *RepresentorCache) GetVfRepresentorDPU(pfID, vfIndex string) (string, error) {
+	key := repCacheKey{lookup: "GetVfRepresentorDPU", dev: fmt.Sprintf("%s/%s", pfID, vfIndex)}
+	return c.lookup(key, func() (string, error) {
+		return c.provider.GetVfRepresentorDPU(pfID, vfIndex)
+	})
+}
+
+// errNotCached is returned by lookup functions whose result should not be cached
+var errNotCached = errors.New("not cached")
+
+// GetRepresentorPeerMacAddress is sriovnet.SwitchdevProvider.GetRepresentorPeerMacAddress served from the cache.
+// Only the peer MAC addresses of PF representors, read from the representor address file, are cached.
+func (c *RepresentorCache) GetRepresentorPeerMacAddress(netdev string) (net.HardwareAddr, error) {
+	key := repCacheKey{lookup: "GetRepresentorPeerMacAddress", dev: netdev}
+	macStr, err := c.lookup(key, func() (string, error) {
+		flavor, err := c.provider.GetRepresentorPortFlavour(netdev)
+		if err != nil || flavor != sriovnet.PORT_FLAVOUR_PCI_PF {
+			return "", errNotCached
+		}
+		mac, err := c.provider.GetRepresentorPeerMacAddress(netdev)
+		if err != nil {
+			return "", err
+		}
+		return mac.String(), nil
+	})
+	if errors.Is(err, errNotCached) {
+		return c.provider.GetRepresentorPeerMacAddress(netdev)
+	}
+	if err != nil {
+		return nil, err
+	}
+	mac, err := net.ParseMAC(macStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cached MAC address \"%s\" for %s. %v", macStr, netdev, err)
+	}
+	return mac, nil
+}
@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package util
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Mellanox/sriovnet"
+	"github.com/Mellanox/sriovnet/pkg/utils/filesystem"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/testing/switchdevfs"
+)
+
+func TestRepresentorCache(t *testing.T) {
+	fs, teardown, err := filesystem.NewFakeFs(filepath.Join(t.TempDir(), "fakefs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	spec := switchdevfs.Spec{Uplinks: []switchdevfs.Uplink{{
+		Name:           "p0",
+		PciAddress:     "0000:03:00.0",
+		SwitchID:       "c2cfc60003a1420c",
+		VfPciAddresses: []string{"0000:03:00.2"},
+		Representors: []switchdevfs.Representor{
+			{Name: "pf0hpf", PhysPortName: "pf0"},
+			{Name: "pf0vf0", PhysPortName: "pf0vf0"},
+		},
+	}}}
+	if err := switchdevfs.Build(fs, sriovnet.NetSysDir, sriovnet.PciSysDir, spec); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewRepresentorCache(sriovnet.NewSwitchdevProvider(sriovnet.NetSysDir, sriovnet.PciSysDir, fs), false)
+	defer cache.Close()
+
+	uplink, err := cache.GetUplinkRepresentor("0000:03:00.2")
+	assert.NoError(t, err)
+	assert.Equal(t, "p0", uplink)
+	rep, err := cache.GetVfRepresentor("p0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "pf0vf0", rep)
+	pfRep, err := cache.GetPfRepresentor("p0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "pf0hpf", pfRep)
+
+	// removed representors are served from the cache until invalidated
+	assert.NoError(t, fs.RemoveAll(filepath.Join(sriovnet.NetSysDir, "pf0vf0")))
+	rep, err = cache.GetVfRepresentor("p0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "pf0vf0", rep)
+
+	cache.InvalidateNetdevs("pf0vf0")
+	_, err = cache.GetVfRepresentor("p0", 0)
+	assert.ErrorIs(t, err, sriovnet.ErrRepresentorNotFound)
+	// entries of other netdevs are kept
+	assert.NoError(t, fs.RemoveAll(filepath.Join(sriovnet.NetSysDir, "pf0hpf")))
+	pfRep, err = cache.GetPfRepresentor("p0", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "pf0hpf", pfRep)
+
+	cache.Invalidate()
+	_, err = cache.GetPfRepresentor("p0", 0)
+	assert.Error(t, err)
+}